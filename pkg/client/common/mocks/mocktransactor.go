@@ -15,9 +15,14 @@ import (
 
 // MockTransactor provides an implementation of Transactor that exposes all its context.
 type MockTransactor struct {
-	Ctx       context.Client
-	ChannelID string
-	Orderers  []fab.Orderer
+	Ctx           context.Client
+	ChannelID     string
+	Orderers      []fab.Orderer
+	BroadcastOpts txn.BroadcastOpts
+	// CannedResponses, if set, short-circuits SendTransactionProposal with
+	// pre-built responses keyed by the proposal's CCProposalType, letting
+	// tests exercise e.g. the 2.x chaincode lifecycle without a running peer.
+	CannedResponses map[txn.CCProposalType][]*fab.TransactionProposalResponse
 }
 
 // CreateTransactionHeader creates a Transaction Header based on the current context.
@@ -31,7 +36,19 @@ func (t *MockTransactor) CreateTransactionHeader() (fab.TransactionHeader, error
 }
 
 // SendTransactionProposal sends a TransactionProposal to the target peers.
+// If CannedResponses is set and the proposal's invoked function maps to a
+// known CCProposalType with a canned entry, that entry is returned instead
+// of contacting targets.
 func (t *MockTransactor) SendTransactionProposal(proposal *fab.TransactionProposal, targets []fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error) {
+	if t.CannedResponses != nil {
+		if fcn, err := txn.ChaincodeInvocationFunction(proposal); err == nil {
+			if ccType, ok := txn.CCProposalTypeForFunction(fcn); ok {
+				if responses, ok := t.CannedResponses[ccType]; ok {
+					return responses, nil
+				}
+			}
+		}
+	}
 	return txn.SendProposal(t.Ctx, proposal, targets)
 }
 
@@ -41,6 +58,7 @@ func (t *MockTransactor) CreateTransaction(request fab.TransactionRequest) (*fab
 }
 
 // SendTransaction send a transaction to the chain’s orderer service (one or more orderer endpoints) for consensus and committing to the ledger.
+// The fan-out strategy is controlled by t.BroadcastOpts (defaults to sequential-random when left at its zero value).
 func (t *MockTransactor) SendTransaction(tx *fab.Transaction) (*fab.TransactionResponse, error) {
-	return txn.Send(t.Ctx, tx, t.Orderers)
+	return txn.SendWithOpts(t.Ctx, tx, t.Orderers, t.BroadcastOpts)
 }
\ No newline at end of file
@@ -0,0 +1,297 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	reqContext "context"
+	"math"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+
+	contextApi "github.com/hyperledger/fabric-sdk-go/pkg/common/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	ab "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/orderer"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	protos_utils "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+)
+
+// defaultNotFoundRetryInterval is how long SendTransactionAndWait waits
+// before re-opening a Deliver stream after reaching the chain tip without
+// having seen the submitted transaction.
+const defaultNotFoundRetryInterval = 500 * time.Millisecond
+
+// defaultPollInterval bounds how long a single Deliver stream attempt is
+// kept open before being closed and reopened. The seek envelope asks the
+// peer to block until new blocks are ready, so without this bound a single
+// attempt would run until the overall deadline and NotFoundRetryInterval
+// would never get a chance to fire.
+const defaultPollInterval = 3 * time.Second
+
+// CommitStatus is the outcome of waiting for a submitted transaction to be
+// validated and committed to the ledger.
+type CommitStatus struct {
+	TxID           string
+	BlockNumber    uint64
+	ValidationCode pb.TxValidationCode
+	// Committed is true when ValidationCode is pb.TxValidationCode_VALID.
+	Committed bool
+}
+
+// Retryable reports whether ValidationCode reflects a transient MVCC/phantom
+// read conflict that a caller submitting an idempotent transaction can
+// safely resubmit.
+func (s *CommitStatus) Retryable() bool {
+	return s.ValidationCode == pb.TxValidationCode_MVCC_READ_CONFLICT ||
+		s.ValidationCode == pb.TxValidationCode_PHANTOM_READ_CONFLICT
+}
+
+// DeliverClient is satisfied by any endpoint able to serve a block Deliver
+// stream for a signed envelope, such as fab.Orderer. Declaring it separately
+// (rather than requiring fab.Orderer) lets SendTransactionAndWait reuse
+// sendEnvelope's SendDeliver path against peers too.
+type DeliverClient interface {
+	URL() string
+	SendDeliver(ctx reqContext.Context, envelope *fab.SignedEnvelope) (chan *common.Block, chan error)
+}
+
+// CommitWaitOpts controls SendTransactionAndWait's retry behavior while
+// scanning delivered blocks for the submitted transaction.
+type CommitWaitOpts struct {
+	// NotFoundRetryInterval is how long to wait before re-opening the
+	// Deliver stream after reaching the chain tip without finding the
+	// transaction. Defaults to defaultNotFoundRetryInterval.
+	NotFoundRetryInterval time.Duration
+	// PollInterval bounds how long a single Deliver stream attempt is kept
+	// open before being closed and reopened, giving NotFoundRetryInterval a
+	// chance to apply instead of one attempt consuming the whole remaining
+	// deadline. Defaults to defaultPollInterval.
+	PollInterval time.Duration
+}
+
+// SendTransactionAndWait broadcasts tx to orderers and then opens a Deliver
+// stream against peers (failing over to the next peer in the slice on a
+// connection error) to wait until the transaction's ValidationCode is
+// reported or timeout elapses. timeout is split evenly across peers, so one
+// slow-but-healthy peer cannot consume the whole deadline and leave nothing
+// for the peers tried after it.
+func SendTransactionAndWait(ctx contextApi.Client, channelID string, tx *fab.Transaction, orderers []fab.Orderer, peers []DeliverClient, timeout time.Duration) (*CommitStatus, error) {
+	return SendTransactionAndWaitWithOpts(ctx, channelID, tx, orderers, peers, timeout, CommitWaitOpts{})
+}
+
+// SendTransactionAndWaitWithOpts is SendTransactionAndWait with control over
+// the NOT_FOUND retry interval. See CommitWaitOpts.
+func SendTransactionAndWaitWithOpts(ctx contextApi.Client, channelID string, tx *fab.Transaction, orderers []fab.Orderer, peers []DeliverClient, timeout time.Duration, opts CommitWaitOpts) (*CommitStatus, error) {
+	if tx == nil {
+		return nil, errors.New("transaction is nil")
+	}
+	if len(peers) == 0 {
+		return nil, errors.New("at least one peer is necessary to wait for commit")
+	}
+
+	txID, err := txnID(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := Send(ctx, tx, orderers); err != nil {
+		return nil, errors.WithMessage(err, "broadcasting transaction failed")
+	}
+
+	return waitForCommitAcrossPeers(peers, timeout, func(peer DeliverClient, deadline time.Time) (*CommitStatus, error) {
+		return waitForCommit(ctx, channelID, peer, txID, deadline, opts)
+	})
+}
+
+// waitForCommitAcrossPeers tries each peer in turn via wait, splitting
+// timeout evenly across them so that a peer which is simply slow to see the
+// commit (no hard error, just still waiting) cannot exhaust the whole
+// deadline and starve every peer tried after it. Failover on a hard error
+// still moves to the next peer immediately, with its own full share of the
+// budget. Split out from SendTransactionAndWaitWithOpts so the per-peer
+// timeout split and failover behavior can be exercised directly against a
+// stub wait, independent of contextApi.Client.
+func waitForCommitAcrossPeers(peers []DeliverClient, timeout time.Duration, wait func(peer DeliverClient, deadline time.Time) (*CommitStatus, error)) (*CommitStatus, error) {
+	perPeerTimeout := timeout / time.Duration(len(peers))
+	var lastErr error
+	for _, peer := range peers {
+		deadline := time.Now().Add(perPeerTimeout)
+		status, err := wait(peer, deadline)
+		if err == nil {
+			return status, nil
+		}
+		lastErr = err
+		logger.Debugf("waiting for commit on peer '%s' failed, trying next peer: %v\n", peer.URL(), err)
+	}
+	return nil, errors.WithMessage(lastErr, "waiting for commit failed on all peers")
+}
+
+func txnID(tx *fab.Transaction) (string, error) {
+	if tx.Proposal == nil || tx.Proposal.TxnID.ID == "" {
+		return "", errors.New("transaction has no TxnID")
+	}
+	return tx.Proposal.TxnID.ID, nil
+}
+
+// waitForCommit repeatedly opens a Deliver stream against peer, scanning
+// each delivered block for txID, until it is found or the deadline elapses.
+func waitForCommit(ctx contextApi.Client, channelID string, peer DeliverClient, txID string, deadline time.Time, opts CommitWaitOpts) (*CommitStatus, error) {
+	return pollForCommit(context.NewRequest(ctx), peer, txID, deadline, opts, func() (*fab.SignedEnvelope, error) {
+		return newDeliverSeekEnvelope(ctx, channelID)
+	})
+}
+
+// pollForCommit is waitForCommit's retry loop. Each stream attempt is
+// bounded by opts.PollInterval (not the full remaining deadline): the seek
+// envelope asks the peer to block for new blocks, so closing and reopening
+// the stream on that shorter cadence is what actually gives a NOT_FOUND
+// attempt a chance to wait opts.NotFoundRetryInterval before trying again.
+// Split out from waitForCommit so the retry/poll-interval cycling can be
+// exercised directly against a fake DeliverClient and a stub envelope
+// builder, independent of contextApi.Client.
+func pollForCommit(baseCtx reqContext.Context, peer DeliverClient, txID string, deadline time.Time, opts CommitWaitOpts, newEnvelope func() (*fab.SignedEnvelope, error)) (*CommitStatus, error) {
+	retryInterval := opts.NotFoundRetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultNotFoundRetryInterval
+	}
+	pollInterval := opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, errors.Errorf("timed out waiting for commit of transaction '%s'", txID)
+		}
+		attemptTimeout := remaining
+		if attemptTimeout > pollInterval {
+			attemptTimeout = pollInterval
+		}
+
+		envelope, err := newEnvelope()
+		if err != nil {
+			return nil, err
+		}
+
+		reqCtx, cancel := reqContext.WithTimeout(baseCtx, attemptTimeout)
+		status, found, err := scanDeliverStream(reqCtx, peer, envelope, txID)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			return status, nil
+		}
+
+		// Attempt timed out (NOT_FOUND so far): wait, then reopen.
+		time.Sleep(retryInterval)
+	}
+}
+
+// scanDeliverStream reads blocks from peer's Deliver stream until txID is
+// found, the stream ends (chain tip reached, i.e. NOT_FOUND), or reqCtx is
+// done.
+func scanDeliverStream(reqCtx reqContext.Context, peer DeliverClient, envelope *fab.SignedEnvelope, txID string) (*CommitStatus, bool, error) {
+	blocks, errs := peer.SendDeliver(reqCtx, envelope)
+	for {
+		select {
+		case b, ok := <-blocks:
+			if !ok {
+				return nil, false, nil
+			}
+			if status, found := findTxInBlock(b, txID); found {
+				return status, true, nil
+			}
+		case err := <-errs:
+			return nil, false, errors.Wrapf(err, "error from peer '%s'", peer.URL())
+		case <-reqCtx.Done():
+			return nil, false, nil
+		}
+	}
+}
+
+// findTxInBlock scans a delivered block's transactions for txID, returning
+// its validation code from the block's transaction-filter metadata.
+func findTxInBlock(block *common.Block, txID string) (*CommitStatus, bool) {
+	if block == nil || block.Data == nil || block.Metadata == nil {
+		return nil, false
+	}
+	filter := block.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+
+	for i, envBytes := range block.Data.Data {
+		env, err := protos_utils.GetEnvelopeFromBlock(envBytes)
+		if err != nil {
+			continue
+		}
+		payload, err := protos_utils.GetPayload(env)
+		if err != nil {
+			continue
+		}
+		chdr, err := protos_utils.UnmarshalChannelHeader(payload.Header.ChannelHeader)
+		if err != nil {
+			continue
+		}
+		if chdr.TxId != txID {
+			continue
+		}
+
+		code := pb.TxValidationCode_VALID
+		if i < len(filter) {
+			code = pb.TxValidationCode(filter[i])
+		}
+		return &CommitStatus{
+			TxID:           txID,
+			BlockNumber:    block.Header.Number,
+			ValidationCode: code,
+			Committed:      code == pb.TxValidationCode_VALID,
+		}, true
+	}
+	return nil, false
+}
+
+// newDeliverSeekEnvelope builds a signed envelope requesting delivery of
+// blocks from the current chain tip onward, blocking until each is ready.
+func newDeliverSeekEnvelope(ctx contextApi.Client, channelID string) (*fab.SignedEnvelope, error) {
+	txh, err := NewHeader(ctx, channelID)
+	if err != nil {
+		return nil, errors.WithMessage(err, "new transaction header failed")
+	}
+
+	seekInfo := &ab.SeekInfo{
+		Start:    &ab.SeekPosition{Type: &ab.SeekPosition_Newest{Newest: &ab.SeekNewest{}}},
+		Stop:     &ab.SeekPosition{Type: &ab.SeekPosition_Specified{Specified: &ab.SeekSpecified{Number: math.MaxUint64}}},
+		Behavior: ab.SeekInfo_BLOCK_UNTIL_READY,
+	}
+	seekInfoBytes, err := proto.Marshal(seekInfo)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal seek info failed")
+	}
+
+	chdrBytes, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_DELIVER_SEEK_INFO),
+		ChannelId: channelID,
+		TxId:      txh.TransactionID(),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal channel header failed")
+	}
+	shdrBytes, err := proto.Marshal(&common.SignatureHeader{Creator: txh.Creator(), Nonce: txh.Nonce()})
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal signature header failed")
+	}
+
+	payload := &common.Payload{
+		Header: &common.Header{ChannelHeader: chdrBytes, SignatureHeader: shdrBytes},
+		Data:   seekInfoBytes,
+	}
+
+	return signPayload(ctx, payload)
+}
@@ -0,0 +1,263 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	reqContext "context"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+// newTestBlock builds a *common.Block carrying a single transaction with
+// txID and validationCode, the shape findTxInBlock expects to scan.
+func newTestBlock(t testing.TB, number uint64, txID string, validationCode pb.TxValidationCode) *common.Block {
+	t.Helper()
+
+	chdrBytes, err := proto.Marshal(&common.ChannelHeader{TxId: txID})
+	if err != nil {
+		t.Fatalf("marshal channel header failed: %v", err)
+	}
+	payloadBytes, err := proto.Marshal(&common.Payload{Header: &common.Header{ChannelHeader: chdrBytes}})
+	if err != nil {
+		t.Fatalf("marshal payload failed: %v", err)
+	}
+	envBytes, err := proto.Marshal(&common.Envelope{Payload: payloadBytes})
+	if err != nil {
+		t.Fatalf("marshal envelope failed: %v", err)
+	}
+
+	metadata := make([][]byte, common.BlockMetadataIndex_TRANSACTIONS_FILTER+1)
+	metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = []byte{byte(validationCode)}
+
+	return &common.Block{
+		Header:   &common.BlockHeader{Number: number},
+		Data:     &common.BlockData{Data: [][]byte{envBytes}},
+		Metadata: &common.BlockMetadata{Metadata: metadata},
+	}
+}
+
+func TestFindTxInBlockFound(t *testing.T) {
+	block := newTestBlock(t, 7, "tx1", pb.TxValidationCode_VALID)
+
+	status, found := findTxInBlock(block, "tx1")
+	if !found {
+		t.Fatal("expected to find the transaction")
+	}
+	if status.BlockNumber != 7 || !status.Committed {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestFindTxInBlockMVCCConflict(t *testing.T) {
+	block := newTestBlock(t, 1, "tx1", pb.TxValidationCode_MVCC_READ_CONFLICT)
+
+	status, found := findTxInBlock(block, "tx1")
+	if !found {
+		t.Fatal("expected to find the transaction")
+	}
+	if status.Committed {
+		t.Fatal("expected an MVCC conflict to not be committed")
+	}
+	if !status.Retryable() {
+		t.Fatal("expected an MVCC conflict to be retryable")
+	}
+}
+
+func TestFindTxInBlockNotFound(t *testing.T) {
+	block := newTestBlock(t, 1, "other-tx", pb.TxValidationCode_VALID)
+
+	if _, found := findTxInBlock(block, "tx1"); found {
+		t.Fatal("expected the transaction to not be found")
+	}
+}
+
+// scriptedAttempt is one SendDeliver call's scripted behavior.
+type scriptedAttempt struct {
+	blocks []*common.Block
+	err    error
+	// hang keeps the stream open (neither closing blocks nor sending an
+	// error) until the caller's context is done, simulating a peer that
+	// has nothing new to deliver under BLOCK_UNTIL_READY.
+	hang bool
+}
+
+type scriptedDeliverClient struct {
+	url      string
+	attempts []scriptedAttempt
+	calls    int
+}
+
+func (c *scriptedDeliverClient) URL() string {
+	return c.url
+}
+
+func (c *scriptedDeliverClient) SendDeliver(ctx reqContext.Context, envelope *fab.SignedEnvelope) (chan *common.Block, chan error) {
+	var attempt scriptedAttempt
+	if c.calls < len(c.attempts) {
+		attempt = c.attempts[c.calls]
+	}
+	c.calls++
+
+	blocks := make(chan *common.Block, len(attempt.blocks))
+	errs := make(chan error, 1)
+	go func() {
+		for _, b := range attempt.blocks {
+			blocks <- b
+		}
+		if attempt.err != nil {
+			errs <- attempt.err
+			return
+		}
+		if attempt.hang {
+			<-ctx.Done()
+			return
+		}
+		close(blocks)
+	}()
+	return blocks, errs
+}
+
+func TestScanDeliverStreamFindsTx(t *testing.T) {
+	peer := &scriptedDeliverClient{attempts: []scriptedAttempt{
+		{blocks: []*common.Block{newTestBlock(t, 1, "tx1", pb.TxValidationCode_VALID)}},
+	}}
+
+	status, found, err := scanDeliverStream(reqContext.Background(), peer, &fab.SignedEnvelope{}, "tx1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !found || status.BlockNumber != 1 {
+		t.Fatalf("expected to find tx1 in block 1, got status=%+v found=%v", status, found)
+	}
+}
+
+func TestScanDeliverStreamPropagatesPeerError(t *testing.T) {
+	peer := &scriptedDeliverClient{attempts: []scriptedAttempt{{err: errTest}}}
+
+	if _, _, err := scanDeliverStream(reqContext.Background(), peer, &fab.SignedEnvelope{}, "tx1"); err == nil {
+		t.Fatal("expected the peer's error to be propagated")
+	}
+}
+
+func TestPollForCommitFindsTxInFirstBlock(t *testing.T) {
+	peer := &scriptedDeliverClient{attempts: []scriptedAttempt{
+		{blocks: []*common.Block{newTestBlock(t, 1, "tx1", pb.TxValidationCode_VALID)}},
+	}}
+
+	status, err := pollForCommit(reqContext.Background(), peer, "tx1", time.Now().Add(time.Second), CommitWaitOpts{}, stubEnvelope)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Committed {
+		t.Fatal("expected the transaction to be committed")
+	}
+}
+
+// TestPollForCommitRetriesAfterNotFound exercises the chunk0-4 fix directly:
+// the first attempt hangs (simulating reaching the chain tip with nothing
+// new, i.e. NOT_FOUND) until its short PollInterval elapses, then the retry
+// finds the transaction. If the poll interval didn't actually bound the
+// attempt, this would time out instead of succeeding.
+func TestPollForCommitRetriesAfterNotFound(t *testing.T) {
+	peer := &scriptedDeliverClient{attempts: []scriptedAttempt{
+		{hang: true},
+		{blocks: []*common.Block{newTestBlock(t, 1, "tx1", pb.TxValidationCode_VALID)}},
+	}}
+	opts := CommitWaitOpts{PollInterval: 20 * time.Millisecond, NotFoundRetryInterval: 5 * time.Millisecond}
+
+	status, err := pollForCommit(reqContext.Background(), peer, "tx1", time.Now().Add(time.Second), opts, stubEnvelope)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Committed {
+		t.Fatal("expected the transaction to be committed")
+	}
+	if peer.calls < 2 {
+		t.Fatalf("expected at least 2 attempts, got %d", peer.calls)
+	}
+}
+
+func TestPollForCommitTimesOut(t *testing.T) {
+	peer := &scriptedDeliverClient{attempts: []scriptedAttempt{{hang: true}, {hang: true}, {hang: true}}}
+	opts := CommitWaitOpts{PollInterval: 10 * time.Millisecond, NotFoundRetryInterval: time.Millisecond}
+
+	if _, err := pollForCommit(reqContext.Background(), peer, "tx1", time.Now().Add(30*time.Millisecond), opts, stubEnvelope); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+}
+
+func stubEnvelope() (*fab.SignedEnvelope, error) {
+	return &fab.SignedEnvelope{}, nil
+}
+
+// TestWaitForCommitAcrossPeersFailsOverOnError exercises peer failover: the
+// first peer's wait fails (a hard error, not mere slowness) and the second
+// peer's succeeds.
+func TestWaitForCommitAcrossPeersFailsOverOnError(t *testing.T) {
+	peer1 := &scriptedDeliverClient{url: "peer1"}
+	peer2 := &scriptedDeliverClient{url: "peer2"}
+	peers := []DeliverClient{peer1, peer2}
+
+	wait := func(peer DeliverClient, deadline time.Time) (*CommitStatus, error) {
+		if peer.URL() == "peer1" {
+			return nil, errTest
+		}
+		return &CommitStatus{TxID: "tx1", Committed: true}, nil
+	}
+
+	status, err := waitForCommitAcrossPeers(peers, time.Second, wait)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !status.Committed {
+		t.Fatal("expected the second peer's result to be returned")
+	}
+}
+
+func TestWaitForCommitAcrossPeersSplitsTimeoutBudget(t *testing.T) {
+	peers := []DeliverClient{
+		&scriptedDeliverClient{url: "peer1"},
+		&scriptedDeliverClient{url: "peer2"},
+		&scriptedDeliverClient{url: "peer3"},
+	}
+
+	var gotDeadlines []time.Time
+	start := time.Now()
+	wait := func(peer DeliverClient, deadline time.Time) (*CommitStatus, error) {
+		gotDeadlines = append(gotDeadlines, deadline)
+		return nil, errTest
+	}
+
+	if _, err := waitForCommitAcrossPeers(peers, 300*time.Millisecond, wait); err == nil {
+		t.Fatal("expected an error when every peer fails")
+	}
+	if len(gotDeadlines) != len(peers) {
+		t.Fatalf("expected every peer to be tried, got %d deadlines", len(gotDeadlines))
+	}
+	for _, d := range gotDeadlines {
+		if d.Sub(start) >= 300*time.Millisecond {
+			t.Fatalf("expected each peer's deadline to be a fraction of the total timeout, got %s from start", d.Sub(start))
+		}
+	}
+}
+
+func TestWaitForCommitAcrossPeersAllFail(t *testing.T) {
+	peers := []DeliverClient{&scriptedDeliverClient{url: "peer1"}}
+	wait := func(peer DeliverClient, deadline time.Time) (*CommitStatus, error) {
+		return nil, errTest
+	}
+
+	if _, err := waitForCommitAcrossPeers(peers, time.Second, wait); err == nil {
+		t.Fatal("expected an error when every peer fails")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	"bytes"
+	reqContext "context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+// EndorsementPolicy decides, given the endorser identities collected so far,
+// whether enough endorsements have been gathered to build a transaction.
+// Implementations must be safe to call repeatedly as identities grows.
+type EndorsementPolicy interface {
+	// Satisfied reports whether identities already satisfies the policy.
+	Satisfied(identities [][]byte) bool
+}
+
+// MinEndorsements is an EndorsementPolicy satisfied once at least N distinct
+// endorsers have endorsed.
+type MinEndorsements int
+
+// Satisfied implements EndorsementPolicy.
+func (n MinEndorsements) Satisfied(identities [][]byte) bool {
+	return len(identities) >= int(n)
+}
+
+// PayloadMismatchError is returned by CollectEndorsements when two proposal
+// responses in the same collection disagree on the ProposalResponsePayload,
+// which means the peers simulated the transaction differently (e.g. due to a
+// state fork) and it cannot be committed.
+type PayloadMismatchError struct {
+	ExpectedHash string
+	ActualHash   string
+}
+
+func (e *PayloadMismatchError) Error() string {
+	return fmt.Sprintf("proposal response payloads are not the same: expected hash %s, got %s", e.ExpectedHash, e.ActualHash)
+}
+
+func payloadHash(payload []byte) string {
+	h := sha256.Sum256(payload)
+	return fmt.Sprintf("%x", h)
+}
+
+// EndorsementSet is the result of CollectEndorsements: a single canonical
+// ProposalResponsePayload plus the distinct endorsements gathered for it. It
+// intentionally does not retain the TransactionProposalResponse values it was
+// built from.
+type EndorsementSet struct {
+	Proposal        *fab.TransactionProposal
+	ResponsePayload []byte
+	Endorsements    []*pb.Endorsement
+}
+
+// Transaction builds the final *fab.Transaction from the collected
+// endorsements, following the same construction New uses.
+func (s *EndorsementSet) Transaction() (*fab.Transaction, error) {
+	return buildTransaction(s.Proposal, s.ResponsePayload, s.Endorsements)
+}
+
+// CollectEndorsements consumes proposal responses as they arrive on
+// responses, keeping only a single canonical ProposalResponsePayload plus the
+// set of endorsements seen for it (deduplicated by endorser identity), and
+// returns as soon as policy is satisfiable. It never retains the
+// TransactionProposalResponse values themselves past the call, unlike New,
+// which keeps the full slice for the lifetime of the request.
+//
+// CollectEndorsements returns a *PayloadMismatchError if a later response's
+// payload disagrees with the first one seen. It stops reading from responses
+// and returns ctx.Err(), wrapped with errors.Wrap, if ctx is done before the
+// policy is satisfied.
+func CollectEndorsements(ctx reqContext.Context, proposal *fab.TransactionProposal, responses <-chan *fab.TransactionProposalResponse, policy EndorsementPolicy) (*EndorsementSet, error) {
+	if policy == nil {
+		return nil, errors.New("policy is nil")
+	}
+
+	var canonicalPayload []byte
+	seen := make(map[string]bool)
+	var endorsements []*pb.Endorsement
+	var identities [][]byte
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "collecting endorsements")
+		case r, ok := <-responses:
+			if !ok {
+				return nil, errors.Errorf("endorsement channel closed with %d of the required endorsements", len(endorsements))
+			}
+			if r.ProposalResponse.Response.Status != 200 {
+				return nil, errors.Errorf("proposal response was not successful, error code %d, msg %s", r.ProposalResponse.Response.Status, r.ProposalResponse.Response.Message)
+			}
+
+			if canonicalPayload == nil {
+				canonicalPayload = r.ProposalResponse.Payload
+			} else if !bytes.Equal(canonicalPayload, r.ProposalResponse.Payload) {
+				return nil, &PayloadMismatchError{
+					ExpectedHash: payloadHash(canonicalPayload),
+					ActualHash:   payloadHash(r.ProposalResponse.Payload),
+				}
+			}
+
+			identity := r.ProposalResponse.Endorsement.Endorser
+			if seen[string(identity)] {
+				continue
+			}
+			seen[string(identity)] = true
+			endorsements = append(endorsements, r.ProposalResponse.Endorsement)
+			identities = append(identities, identity)
+
+			if policy.Satisfied(identities) {
+				return &EndorsementSet{
+					Proposal:        proposal,
+					ResponsePayload: canonicalPayload,
+					Endorsements:    endorsements,
+				}, nil
+			}
+		}
+	}
+}
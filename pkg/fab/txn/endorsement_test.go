@@ -0,0 +1,202 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	reqContext "context"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+// newTestProposal builds a *fab.TransactionProposal that New and
+// CollectEndorsements can both turn into a *fab.Transaction, so the two APIs
+// can be exercised (and benchmarked) against identical input.
+func newTestProposal(t testing.TB) *fab.TransactionProposal {
+	t.Helper()
+
+	extBytes, err := proto.Marshal(&pb.ChaincodeHeaderExtension{})
+	if err != nil {
+		t.Fatalf("marshal chaincode header extension failed: %v", err)
+	}
+	chdrBytes, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		ChannelId: "mychannel",
+		Extension: extBytes,
+	})
+	if err != nil {
+		t.Fatalf("marshal channel header failed: %v", err)
+	}
+	shdrBytes, err := proto.Marshal(&common.SignatureHeader{Creator: []byte("creator"), Nonce: []byte("nonce")})
+	if err != nil {
+		t.Fatalf("marshal signature header failed: %v", err)
+	}
+	hdrBytes, err := proto.Marshal(&common.Header{ChannelHeader: chdrBytes, SignatureHeader: shdrBytes})
+	if err != nil {
+		t.Fatalf("marshal header failed: %v", err)
+	}
+	payloadBytes, err := proto.Marshal(&pb.ChaincodeProposalPayload{Input: []byte("input")})
+	if err != nil {
+		t.Fatalf("marshal chaincode proposal payload failed: %v", err)
+	}
+
+	return &fab.TransactionProposal{
+		Proposal: &pb.Proposal{Header: hdrBytes, Payload: payloadBytes},
+	}
+}
+
+func newTestProposalResponse(endorser string, responsePayload []byte) *fab.TransactionProposalResponse {
+	return &fab.TransactionProposalResponse{
+		ProposalResponse: &pb.ProposalResponse{
+			Response: &pb.Response{Status: 200},
+			Payload:  responsePayload,
+			Endorsement: &pb.Endorsement{
+				Endorser: []byte(endorser),
+			},
+		},
+	}
+}
+
+func TestCollectEndorsementsSuccess(t *testing.T) {
+	proposal := newTestProposal(t)
+	responses := make(chan *fab.TransactionProposalResponse, 2)
+	responses <- newTestProposalResponse("peer0", []byte("payload"))
+	responses <- newTestProposalResponse("peer1", []byte("payload"))
+
+	set, err := CollectEndorsements(reqContext.Background(), proposal, responses, MinEndorsements(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(set.Endorsements) != 2 {
+		t.Fatalf("expected 2 endorsements, got %d", len(set.Endorsements))
+	}
+}
+
+func TestCollectEndorsementsDedupesByEndorser(t *testing.T) {
+	proposal := newTestProposal(t)
+	responses := make(chan *fab.TransactionProposalResponse, 3)
+	responses <- newTestProposalResponse("peer0", []byte("payload"))
+	responses <- newTestProposalResponse("peer0", []byte("payload"))
+	responses <- newTestProposalResponse("peer1", []byte("payload"))
+	close(responses)
+
+	set, err := CollectEndorsements(reqContext.Background(), proposal, responses, MinEndorsements(2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(set.Endorsements) != 2 || string(set.Endorsements[1].Endorser) != "peer1" {
+		t.Fatalf("expected the duplicate endorser to be deduped, got %d endorsements", len(set.Endorsements))
+	}
+}
+
+func TestCollectEndorsementsPayloadMismatch(t *testing.T) {
+	proposal := newTestProposal(t)
+	responses := make(chan *fab.TransactionProposalResponse, 2)
+	responses <- newTestProposalResponse("peer0", []byte("payload-a"))
+	responses <- newTestProposalResponse("peer1", []byte("payload-b"))
+
+	_, err := CollectEndorsements(reqContext.Background(), proposal, responses, MinEndorsements(2))
+	if _, ok := err.(*PayloadMismatchError); !ok {
+		t.Fatalf("expected a *PayloadMismatchError, got %v (%T)", err, err)
+	}
+}
+
+func TestCollectEndorsementsNonSuccessStatus(t *testing.T) {
+	proposal := newTestProposal(t)
+	response := newTestProposalResponse("peer0", []byte("payload"))
+	response.ProposalResponse.Response.Status = 500
+
+	responses := make(chan *fab.TransactionProposalResponse, 1)
+	responses <- response
+
+	if _, err := CollectEndorsements(reqContext.Background(), proposal, responses, MinEndorsements(1)); err == nil {
+		t.Fatal("expected an error for a non-200 proposal response")
+	}
+}
+
+func TestCollectEndorsementsCancelledContext(t *testing.T) {
+	proposal := newTestProposal(t)
+	ctx, cancel := reqContext.WithCancel(reqContext.Background())
+	cancel()
+
+	responses := make(chan *fab.TransactionProposalResponse)
+	if _, err := CollectEndorsements(ctx, proposal, responses, MinEndorsements(1)); err == nil {
+		t.Fatal("expected an error from an already-cancelled context")
+	}
+}
+
+func TestCollectEndorsementsNilPolicy(t *testing.T) {
+	proposal := newTestProposal(t)
+	responses := make(chan *fab.TransactionProposalResponse)
+	if _, err := CollectEndorsements(reqContext.Background(), proposal, responses, nil); err == nil {
+		t.Fatal("expected an error for a nil policy")
+	}
+}
+
+// TestNewToleratesDuplicateEndorsers guards against a regression where New's
+// required-endorsement count was request.ProposalResponses' raw length: once
+// CollectEndorsements dedups two responses from the same endorser down to
+// one, that count could never be satisfied and New would fail even though
+// the original, pre-dedup New happily built a transaction from them.
+func TestNewToleratesDuplicateEndorsers(t *testing.T) {
+	request := fab.TransactionRequest{
+		Proposal: newTestProposal(t),
+		ProposalResponses: []*fab.TransactionProposalResponse{
+			newTestProposalResponse("peer0", []byte("payload")),
+			newTestProposalResponse("peer0", []byte("payload")),
+		},
+	}
+
+	if _, err := New(request); err != nil {
+		t.Fatalf("expected duplicate endorsers to still produce a transaction, got error: %v", err)
+	}
+}
+
+func newTransactionRequest(t testing.TB, n int) fab.TransactionRequest {
+	t.Helper()
+	responses := make([]*fab.TransactionProposalResponse, n)
+	for i := range responses {
+		responses[i] = newTestProposalResponse(string(rune('a'+i)), []byte("payload"))
+	}
+	return fab.TransactionRequest{
+		Proposal:          newTestProposal(t),
+		ProposalResponses: responses,
+	}
+}
+
+// BenchmarkNew and BenchmarkCollectEndorsements run the all-at-once API and
+// the streaming collector on identical input. New feeds its slice through
+// CollectEndorsements and then builds the transaction, so BenchmarkNew covers
+// collection plus transaction assembly while BenchmarkCollectEndorsements
+// covers collection alone.
+func BenchmarkNew(b *testing.B) {
+	request := newTransactionRequest(b, 3)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := New(request); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func BenchmarkCollectEndorsements(b *testing.B) {
+	proposal := newTestProposal(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		responses := make(chan *fab.TransactionProposalResponse, 3)
+		responses <- newTestProposalResponse("a", []byte("payload"))
+		responses <- newTestProposalResponse("b", []byte("payload"))
+		responses <- newTestProposalResponse("c", []byte("payload"))
+		if _, err := CollectEndorsements(reqContext.Background(), proposal, responses, MinEndorsements(3)); err != nil {
+			b.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	reqContext "context"
+
+	"github.com/pkg/errors"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+)
+
+// errTest is a sentinel used by tests that only care that an outcome failed,
+// not why.
+var errTest = errors.New("test error")
+
+// fakeOrderer is a minimal fab.Orderer stand-in for exercising OrdererSelector
+// implementations without a running orderer.
+type fakeOrderer struct {
+	url string
+}
+
+func (f *fakeOrderer) URL() string {
+	return f.url
+}
+
+func (f *fakeOrderer) SendBroadcast(ctx reqContext.Context, envelope *fab.SignedEnvelope) (*common.Status, error) {
+	return nil, nil
+}
+
+func (f *fakeOrderer) SendDeliver(ctx reqContext.Context, envelope *fab.SignedEnvelope) (chan *common.Block, chan error) {
+	return nil, nil
+}
+
+func newFakeOrderers(urls ...string) []fab.Orderer {
+	orderers := make([]fab.Orderer, len(urls))
+	for i, url := range urls {
+		orderers[i] = &fakeOrderer{url: url}
+	}
+	return orderers
+}
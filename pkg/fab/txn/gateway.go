@@ -0,0 +1,233 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	reqContext "context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	contextApi "github.com/hyperledger/fabric-sdk-go/pkg/common/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/context"
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/core"
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+	"github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/common"
+)
+
+// EndorsementGroup is a set of peers belonging to one org, any one of which
+// can serve that org's required endorsement slot in an EndorsementLayout.
+type EndorsementGroup struct {
+	Name  string
+	Peers []fab.ProposalProcessor
+}
+
+// EndorsementLayout is one minimal combination of org groups that together
+// satisfy an endorsement policy: every group must contribute exactly one
+// endorsement.
+type EndorsementLayout struct {
+	Groups []EndorsementGroup
+}
+
+// DiscoveryClient computes the endorsement layouts that satisfy policy for a
+// chaincode, as Fabric's discovery service does.
+type DiscoveryClient interface {
+	PeersForEndorsement(ctx reqContext.Context, ccID string, policy *common.SignaturePolicyEnvelope) ([]*EndorsementLayout, error)
+}
+
+// LayoutSelector deterministically picks which of the layouts discovery
+// offered to pursue.
+type LayoutSelector interface {
+	// Select returns the layout to pursue, or nil if none is usable.
+	Select(layouts []*EndorsementLayout) *EndorsementLayout
+}
+
+// FirstLayoutSelector selects the first layout, relying on discovery having
+// already ordered layouts by preference. This is the default.
+type FirstLayoutSelector struct{}
+
+// Select implements LayoutSelector.
+func (FirstLayoutSelector) Select(layouts []*EndorsementLayout) *EndorsementLayout {
+	if len(layouts) == 0 {
+		return nil
+	}
+	return layouts[0]
+}
+
+// SmallestLayoutSelector selects the layout with the fewest groups, breaking
+// ties by discovery's original ordering, to minimize the number of orgs that
+// must endorse.
+type SmallestLayoutSelector struct{}
+
+// Select implements LayoutSelector.
+func (SmallestLayoutSelector) Select(layouts []*EndorsementLayout) *EndorsementLayout {
+	var best *EndorsementLayout
+	for _, l := range layouts {
+		if best == nil || len(l.Groups) < len(best.Groups) {
+			best = l
+		}
+	}
+	return best
+}
+
+type endorseConfig struct {
+	layoutSelector LayoutSelector
+}
+
+// EndorseOpt configures EndorseWithPolicy.
+type EndorseOpt func(*endorseConfig)
+
+// WithLayoutSelector overrides the default FirstLayoutSelector used to pick
+// among the layouts discovery offers.
+func WithLayoutSelector(s LayoutSelector) EndorseOpt {
+	return func(c *endorseConfig) { c.layoutSelector = s }
+}
+
+// EndorseWithPolicy borrows the Fabric Gateway endorsement flow: it asks
+// discovery for the layouts that satisfy policy for the chaincode in
+// ccProposal, picks one (via opts' LayoutSelector, FirstLayoutSelector by
+// default), and dispatches one proposal per group in parallel. A group whose
+// primary peer fails falls back to the next peer in the same group without
+// re-running discovery. Successful group responses are fed into
+// CollectEndorsements (the same streaming, payload-comparing collector
+// chunk0-3 introduced for New), which returns as soon as every group's
+// endorsement has been gathered; outstanding attempts are then cancelled and
+// the collected endorsements are returned, ready for
+// (*EndorsementSet).Transaction or passing into a fab.TransactionRequest for
+// New.
+func EndorseWithPolicy(ctx contextApi.Client, header fab.TransactionHeader, ccProposal fab.ChaincodeInvokeRequest, discovery DiscoveryClient, policy *common.SignaturePolicyEnvelope, opts ...EndorseOpt) (*EndorsementSet, error) {
+	cfg := endorseConfig{layoutSelector: FirstLayoutSelector{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	reqCtx, cancel := reqContext.WithTimeout(context.NewRequest(ctx), ctx.Config().TimeoutOrDefault(core.PeerResponse))
+	defer cancel()
+
+	layouts, err := discovery.PeersForEndorsement(reqCtx, ccProposal.ChaincodeID, policy)
+	if err != nil {
+		return nil, errors.Wrap(err, "discovery failed")
+	}
+	layout := cfg.layoutSelector.Select(layouts)
+	if layout == nil || len(layout.Groups) == 0 {
+		return nil, errors.New("no endorsement layout satisfies the policy")
+	}
+
+	proposal, err := CreateChaincodeInvokeProposal(header, ccProposal)
+	if err != nil {
+		return nil, err
+	}
+
+	return dispatchEndorsementLayout(reqCtx, proposal, layout, func(peer fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error) {
+		return SendProposal(ctx, proposal, []fab.ProposalProcessor{peer})
+	})
+}
+
+// dispatchEndorsementLayout dispatches one proposal per group in layout in
+// parallel via sendToPeer (which endorseGroup falls back within a group on
+// failure). Successful group responses are fed into CollectEndorsements (the
+// same streaming, payload-comparing collector chunk0-3 introduced for New),
+// which returns as soon as every group's endorsement has been gathered;
+// outstanding attempts are then cancelled and the collected endorsements are
+// returned. A group that exhausts its peers fails the whole call immediately
+// rather than waiting on the other, possibly slower, groups. Split out from
+// EndorseWithPolicy so this fan-out/cancellation/payload-comparison behavior
+// can be exercised directly against a stub sendToPeer, independent of
+// contextApi.Client and DiscoveryClient.
+func dispatchEndorsementLayout(reqCtx reqContext.Context, proposal *fab.TransactionProposal, layout *EndorsementLayout, sendToPeer func(peer fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error)) (*EndorsementSet, error) {
+	groupCtx, cancelGroups := reqContext.WithCancel(reqCtx)
+	defer cancelGroups()
+
+	// Each group's winning response is fed into responses for
+	// CollectEndorsements. Errors (a group exhausting its peers) are
+	// reported separately, since CollectEndorsements only knows how to wait
+	// for more responses or be cancelled, not how to fail fast on a group
+	// that will never produce one.
+	responses := make(chan *fab.TransactionProposalResponse, len(layout.Groups))
+	groupErrs := make(chan error, len(layout.Groups))
+
+	var wg sync.WaitGroup
+	for _, g := range layout.Groups {
+		wg.Add(1)
+		go func(group EndorsementGroup) {
+			defer wg.Done()
+			resp, err := endorseGroup(groupCtx, group, sendToPeer)
+			if err != nil {
+				select {
+				case groupErrs <- errors.Wrapf(err, "group '%s' could not be endorsed", group.Name):
+				case <-groupCtx.Done():
+				}
+				return
+			}
+			select {
+			case responses <- resp:
+			case <-groupCtx.Done():
+			}
+		}(g)
+	}
+	go func() {
+		wg.Wait()
+		close(responses)
+	}()
+
+	type collectResult struct {
+		set *EndorsementSet
+		err error
+	}
+	collected := make(chan collectResult, 1)
+	go func() {
+		set, err := CollectEndorsements(groupCtx, proposal, responses, MinEndorsements(len(layout.Groups)))
+		collected <- collectResult{set: set, err: err}
+	}()
+
+	select {
+	case err := <-groupErrs:
+		cancelGroups()
+		return nil, err
+	case r := <-collected:
+		cancelGroups()
+		if r.err != nil {
+			return nil, r.err
+		}
+		return r.set, nil
+	}
+}
+
+// endorseGroup tries group's peers in order via sendToPeer, returning the
+// first successful endorsement. groupCtx being done aborts before the next
+// peer is tried.
+func endorseGroup(groupCtx reqContext.Context, group EndorsementGroup, sendToPeer func(peer fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error)) (*fab.TransactionProposalResponse, error) {
+	var lastErr error
+	for _, peer := range group.Peers {
+		select {
+		case <-groupCtx.Done():
+			return nil, groupCtx.Err()
+		default:
+		}
+
+		responses, err := sendToPeer(peer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if len(responses) == 0 {
+			lastErr = errors.Errorf("no response from peer in group '%s'", group.Name)
+			continue
+		}
+
+		resp := responses[0]
+		if resp.ProposalResponse.Response.Status != 200 {
+			lastErr = errors.Errorf("endorsement failed, error code %d, msg %s", resp.ProposalResponse.Response.Status, resp.ProposalResponse.Response.Message)
+			continue
+		}
+		return resp, nil
+	}
+	if lastErr == nil {
+		lastErr = errors.Errorf("group '%s' has no peers", group.Name)
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,193 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	reqContext "context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+)
+
+// fakePeer is a minimal fab.ProposalProcessor stand-in used only as an
+// identity (via name) inside EndorsementGroup.Peers; dispatchEndorsementLayout
+// and endorseGroup never call its methods directly, always going through the
+// sendToPeer closure under test, so only its identity needs to be real.
+type fakePeer struct {
+	name string
+}
+
+func (p *fakePeer) ProcessTransactionProposal(ctx reqContext.Context, req fab.ProcessProposalRequest) (*fab.TransactionProposalResponse, error) {
+	return nil, nil
+}
+
+func TestFirstLayoutSelector(t *testing.T) {
+	layouts := []*EndorsementLayout{
+		{Groups: []EndorsementGroup{{Name: "org1"}, {Name: "org2"}}},
+		{Groups: []EndorsementGroup{{Name: "org1"}}},
+	}
+	got := FirstLayoutSelector{}.Select(layouts)
+	if got != layouts[0] {
+		t.Fatalf("expected the first layout to be selected")
+	}
+}
+
+func TestFirstLayoutSelectorEmpty(t *testing.T) {
+	if got := (FirstLayoutSelector{}).Select(nil); got != nil {
+		t.Fatalf("expected nil for no layouts, got %v", got)
+	}
+}
+
+func TestSmallestLayoutSelector(t *testing.T) {
+	layouts := []*EndorsementLayout{
+		{Groups: []EndorsementGroup{{Name: "org1"}, {Name: "org2"}}},
+		{Groups: []EndorsementGroup{{Name: "org1"}}},
+		{Groups: []EndorsementGroup{{Name: "org1"}, {Name: "org2"}, {Name: "org3"}}},
+	}
+	got := SmallestLayoutSelector{}.Select(layouts)
+	if got != layouts[1] {
+		t.Fatalf("expected the layout with fewest groups to be selected, got %v", got)
+	}
+}
+
+func TestSmallestLayoutSelectorEmpty(t *testing.T) {
+	if got := (SmallestLayoutSelector{}).Select(nil); got != nil {
+		t.Fatalf("expected nil for no layouts, got %v", got)
+	}
+}
+
+// TestEndorseGroupFallsBackOnPrimaryFailure exercises endorseGroup's
+// within-group fallback: the primary peer errors and the next peer in the
+// group is tried without re-running discovery.
+func TestEndorseGroupFallsBackOnPrimaryFailure(t *testing.T) {
+	group := EndorsementGroup{
+		Name:  "org1",
+		Peers: []fab.ProposalProcessor{&fakePeer{name: "primary"}, &fakePeer{name: "secondary"}},
+	}
+	send := func(peer fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error) {
+		if peer.(*fakePeer).name == "primary" {
+			return nil, errTest
+		}
+		return []*fab.TransactionProposalResponse{newTestProposalResponse("secondary", []byte("payload"))}, nil
+	}
+
+	resp, err := endorseGroup(reqContext.Background(), group, send)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(resp.ProposalResponse.Endorsement.Endorser) != "secondary" {
+		t.Fatalf("expected the fallback peer's response, got endorser %q", resp.ProposalResponse.Endorsement.Endorser)
+	}
+}
+
+func TestEndorseGroupAllPeersFail(t *testing.T) {
+	group := EndorsementGroup{
+		Name:  "org1",
+		Peers: []fab.ProposalProcessor{&fakePeer{name: "primary"}, &fakePeer{name: "secondary"}},
+	}
+	send := func(peer fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error) {
+		return nil, errTest
+	}
+
+	if _, err := endorseGroup(reqContext.Background(), group, send); err == nil {
+		t.Fatal("expected an error when every peer in the group fails")
+	}
+}
+
+func singlePeerGroup(name string) EndorsementGroup {
+	return EndorsementGroup{Name: name, Peers: []fab.ProposalProcessor{&fakePeer{name: name}}}
+}
+
+// TestDispatchEndorsementLayoutAllGroupsSucceed exercises the happy path:
+// every group in the layout is required (MinEndorsements(len(groups))), so
+// dispatchEndorsementLayout only returns once all of them have answered.
+func TestDispatchEndorsementLayoutAllGroupsSucceed(t *testing.T) {
+	proposal := newTestProposal(t)
+	layout := &EndorsementLayout{Groups: []EndorsementGroup{singlePeerGroup("org1"), singlePeerGroup("org2")}}
+	send := func(peer fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error) {
+		name := peer.(*fakePeer).name
+		return []*fab.TransactionProposalResponse{newTestProposalResponse(name, []byte("payload"))}, nil
+	}
+
+	set, err := dispatchEndorsementLayout(reqContext.Background(), proposal, layout, send)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(set.Endorsements) != 2 {
+		t.Fatalf("expected 2 endorsements, got %d", len(set.Endorsements))
+	}
+}
+
+// TestDispatchEndorsementLayoutCancelsSlowerGroupOnFailure exercises fail-fast
+// cancellation: org1 exhausts its only peer immediately, while org2's primary
+// peer is merely slow to fail. By the time org2 falls back to its secondary
+// peer, org1's failure should already have cancelled groupCtx, so org2 must
+// never try its (much slower) secondary peer at all.
+func TestDispatchEndorsementLayoutCancelsSlowerGroupOnFailure(t *testing.T) {
+	const secondaryDelay = 200 * time.Millisecond
+	proposal := newTestProposal(t)
+	var secondaryCalls int32
+	layout := &EndorsementLayout{Groups: []EndorsementGroup{
+		singlePeerGroup("org1"),
+		{Name: "org2", Peers: []fab.ProposalProcessor{&fakePeer{name: "org2-primary"}, &fakePeer{name: "org2-secondary"}}},
+	}}
+
+	send := func(peer fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error) {
+		switch peer.(*fakePeer).name {
+		case "org1":
+			return nil, errTest
+		case "org2-primary":
+			time.Sleep(20 * time.Millisecond)
+			return nil, errTest
+		default:
+			atomic.AddInt32(&secondaryCalls, 1)
+			time.Sleep(secondaryDelay)
+			return []*fab.TransactionProposalResponse{newTestProposalResponse("org2", []byte("payload"))}, nil
+		}
+	}
+
+	start := time.Now()
+	_, err := dispatchEndorsementLayout(reqContext.Background(), proposal, layout, send)
+	elapsed := time.Since(start)
+	if err == nil {
+		t.Fatal("expected an error when a group exhausts its peers")
+	}
+	if elapsed >= secondaryDelay {
+		t.Fatalf("expected to fail fast rather than wait %s, took %s", secondaryDelay, elapsed)
+	}
+
+	// Give org2's goroutine (still running in the background past
+	// dispatchEndorsementLayout's return) time to reach its fallback peer
+	// check, to prove it never does so once groupCtx is cancelled.
+	time.Sleep(50 * time.Millisecond)
+	if atomic.LoadInt32(&secondaryCalls) != 0 {
+		t.Fatalf("expected org2's cancelled fallback peer to never be tried, got %d calls", secondaryCalls)
+	}
+}
+
+// TestDispatchEndorsementLayoutPayloadMismatch exercises CollectEndorsements'
+// payload comparison across groups: two groups endorse successfully but
+// disagree on the simulated payload, which must surface as a
+// *PayloadMismatchError rather than a generic one.
+func TestDispatchEndorsementLayoutPayloadMismatch(t *testing.T) {
+	proposal := newTestProposal(t)
+	layout := &EndorsementLayout{Groups: []EndorsementGroup{singlePeerGroup("org1"), singlePeerGroup("org2")}}
+	send := func(peer fab.ProposalProcessor) ([]*fab.TransactionProposalResponse, error) {
+		name := peer.(*fakePeer).name
+		if name == "org1" {
+			return []*fab.TransactionProposalResponse{newTestProposalResponse(name, []byte("payload-a"))}, nil
+		}
+		return []*fab.TransactionProposalResponse{newTestProposalResponse(name, []byte("payload-b"))}, nil
+	}
+
+	_, err := dispatchEndorsementLayout(reqContext.Background(), proposal, layout, send)
+	if _, ok := err.(*PayloadMismatchError); !ok {
+		t.Fatalf("expected a *PayloadMismatchError, got %v (%T)", err, err)
+	}
+}
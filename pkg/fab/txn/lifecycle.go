@@ -0,0 +1,188 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+	lb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer/lifecycle"
+	protos_utils "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/utils"
+)
+
+// lifecycleChaincodeName is the Fabric 2.x system chaincode that carries out
+// chaincode lifecycle operations.
+const lifecycleChaincodeName = "_lifecycle"
+
+// Function names invoked on lifecycleChaincodeName, one per CCProposalType
+// added for the 2.x lifecycle.
+const (
+	approveForMyOrgFunc           = "ApproveChaincodeDefinitionForMyOrg"
+	commitChaincodeDefinitionFunc = "CommitChaincodeDefinition"
+	checkCommitReadinessFunc      = "CheckCommitReadiness"
+	queryChaincodeDefinitionFunc  = "QueryChaincodeDefinition"
+)
+
+// functionToCCProposalType maps the function invoked on a system chaincode to
+// the CCProposalType it represents, so that callers (e.g. MockTransactor)
+// can classify a *fab.TransactionProposal they did not build themselves.
+var functionToCCProposalType = map[string]CCProposalType{
+	"deploy":                      Instantiate,
+	"upgrade":                     Upgrade,
+	approveForMyOrgFunc:           ApproveForMyOrg,
+	commitChaincodeDefinitionFunc: CommitChaincodeDefinition,
+	checkCommitReadinessFunc:      CheckCommitReadiness,
+	queryChaincodeDefinitionFunc:  QueryChaincodeDefinition,
+}
+
+// CCProposalTypeForFunction returns the CCProposalType that invoking fcn on a
+// chaincode lifecycle system chaincode (lscc or _lifecycle) represents.
+func CCProposalTypeForFunction(fcn string) (CCProposalType, bool) {
+	t, ok := functionToCCProposalType[fcn]
+	return t, ok
+}
+
+// ChaincodeInvocationFunction extracts the invoked function name (Args[0])
+// from a chaincode transaction proposal.
+func ChaincodeInvocationFunction(proposal *fab.TransactionProposal) (string, error) {
+	if proposal == nil || proposal.Proposal == nil {
+		return "", errors.New("proposal is nil")
+	}
+
+	ccPropPayload, err := protos_utils.GetChaincodeProposalPayload(proposal.Proposal.Payload)
+	if err != nil {
+		return "", errors.Wrap(err, "unmarshal proposal payload failed")
+	}
+
+	cis := &pb.ChaincodeInvocationSpec{}
+	if err := proto.Unmarshal(ccPropPayload.Input, cis); err != nil {
+		return "", errors.Wrap(err, "unmarshal chaincode invocation spec failed")
+	}
+	if cis.ChaincodeSpec == nil || cis.ChaincodeSpec.Input == nil || len(cis.ChaincodeSpec.Input.Args) == 0 {
+		return "", errors.New("chaincode invocation spec has no function")
+	}
+	return string(cis.ChaincodeSpec.Input.Args[0]), nil
+}
+
+// ApproveInput carries the fields of a chaincode definition that this org is
+// approving, per the Fabric 2.x (_lifecycle) ApproveChaincodeDefinitionForMyOrg.
+type ApproveInput struct {
+	Name                string
+	Version             string
+	Sequence            int64
+	PackageID           string
+	EndorsementPlugin   string
+	ValidationPlugin    string
+	ValidationParameter []byte
+	InitRequired        bool
+	Collections         *pb.CollectionConfigPackage
+}
+
+// NewApproveForMyOrgProposal builds the transaction proposal that records
+// this org's approval of a chaincode definition.
+func NewApproveForMyOrgProposal(txh fab.TransactionHeader, input ApproveInput) (*fab.TransactionProposal, error) {
+	source := &lb.ChaincodeSource{Type: &lb.ChaincodeSource_Unavailable_{Unavailable: &lb.ChaincodeSource_Unavailable{}}}
+	if input.PackageID != "" {
+		source = &lb.ChaincodeSource{Type: &lb.ChaincodeSource_LocalPackage{LocalPackage: &lb.ChaincodeSource_Local{PackageId: input.PackageID}}}
+	}
+
+	args := &lb.ApproveChaincodeDefinitionForMyOrgArgs{
+		Name:                input.Name,
+		Version:             input.Version,
+		Sequence:            input.Sequence,
+		Source:              source,
+		EndorsementPlugin:   input.EndorsementPlugin,
+		ValidationPlugin:    input.ValidationPlugin,
+		ValidationParameter: input.ValidationParameter,
+		InitRequired:        input.InitRequired,
+		Collections:         input.Collections,
+	}
+	return newLifecycleProposal(txh, approveForMyOrgFunc, args)
+}
+
+// CommitInput carries the fields of a chaincode definition being committed
+// to the channel.
+type CommitInput struct {
+	Name                string
+	Version             string
+	Sequence            int64
+	EndorsementPlugin   string
+	ValidationPlugin    string
+	ValidationParameter []byte
+	InitRequired        bool
+	Collections         *pb.CollectionConfigPackage
+}
+
+// NewCommitChaincodeDefinitionProposal builds the transaction proposal that
+// commits a chaincode definition to the channel once enough orgs approved it.
+func NewCommitChaincodeDefinitionProposal(txh fab.TransactionHeader, input CommitInput) (*fab.TransactionProposal, error) {
+	args := &lb.CommitChaincodeDefinitionArgs{
+		Name:                input.Name,
+		Version:             input.Version,
+		Sequence:            input.Sequence,
+		EndorsementPlugin:   input.EndorsementPlugin,
+		ValidationPlugin:    input.ValidationPlugin,
+		ValidationParameter: input.ValidationParameter,
+		InitRequired:        input.InitRequired,
+		Collections:         input.Collections,
+	}
+	return newLifecycleProposal(txh, commitChaincodeDefinitionFunc, args)
+}
+
+// CheckCommitReadinessInput carries the chaincode definition to check
+// approval status for.
+type CheckCommitReadinessInput struct {
+	Name                string
+	Version             string
+	Sequence            int64
+	EndorsementPlugin   string
+	ValidationPlugin    string
+	ValidationParameter []byte
+	InitRequired        bool
+	Collections         *pb.CollectionConfigPackage
+}
+
+// NewCheckCommitReadinessProposal builds the transaction proposal that
+// queries which orgs have approved a chaincode definition so far.
+func NewCheckCommitReadinessProposal(txh fab.TransactionHeader, input CheckCommitReadinessInput) (*fab.TransactionProposal, error) {
+	args := &lb.CheckCommitReadinessArgs{
+		Name:                input.Name,
+		Version:             input.Version,
+		Sequence:            input.Sequence,
+		EndorsementPlugin:   input.EndorsementPlugin,
+		ValidationPlugin:    input.ValidationPlugin,
+		ValidationParameter: input.ValidationParameter,
+		InitRequired:        input.InitRequired,
+		Collections:         input.Collections,
+	}
+	return newLifecycleProposal(txh, checkCommitReadinessFunc, args)
+}
+
+// NewQueryChaincodeDefinitionProposal builds the transaction proposal that
+// queries a chaincode definition already committed to the channel.
+func NewQueryChaincodeDefinitionProposal(txh fab.TransactionHeader, name string) (*fab.TransactionProposal, error) {
+	args := &lb.QueryChaincodeDefinitionArgs{Name: name}
+	return newLifecycleProposal(txh, queryChaincodeDefinitionFunc, args)
+}
+
+// newLifecycleProposal builds a transaction proposal invoking fcn on
+// lifecycleChaincodeName with args as its sole, marshaled argument.
+func newLifecycleProposal(txh fab.TransactionHeader, fcn string, args proto.Message) (*fab.TransactionProposal, error) {
+	argBytes, err := proto.Marshal(args)
+	if err != nil {
+		return nil, errors.Wrapf(err, "marshal %s args failed", fcn)
+	}
+
+	request := fab.ChaincodeInvokeRequest{
+		ChaincodeID: lifecycleChaincodeName,
+		Fcn:         fcn,
+		Args:        [][]byte{argBytes},
+	}
+	return CreateChaincodeInvokeProposal(txh, request)
+}
@@ -0,0 +1,78 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+	pb "github.com/hyperledger/fabric-sdk-go/third_party/github.com/hyperledger/fabric/protos/peer"
+)
+
+func TestCCProposalTypeForFunction(t *testing.T) {
+	tests := []struct {
+		fcn  string
+		want CCProposalType
+	}{
+		{approveForMyOrgFunc, ApproveForMyOrg},
+		{commitChaincodeDefinitionFunc, CommitChaincodeDefinition},
+		{checkCommitReadinessFunc, CheckCommitReadiness},
+		{queryChaincodeDefinitionFunc, QueryChaincodeDefinition},
+		{"deploy", Instantiate},
+		{"upgrade", Upgrade},
+	}
+	for _, tt := range tests {
+		got, ok := CCProposalTypeForFunction(tt.fcn)
+		if !ok {
+			t.Errorf("%s: expected a known CCProposalType", tt.fcn)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.fcn, tt.want, got)
+		}
+	}
+}
+
+func TestCCProposalTypeForFunctionUnknown(t *testing.T) {
+	if _, ok := CCProposalTypeForFunction("notAFunction"); ok {
+		t.Fatal("expected an unrecognized function to report ok=false")
+	}
+}
+
+func TestChaincodeInvocationFunction(t *testing.T) {
+	cis := &pb.ChaincodeInvocationSpec{
+		ChaincodeSpec: &pb.ChaincodeSpec{
+			Input: &pb.ChaincodeInput{Args: [][]byte{[]byte(checkCommitReadinessFunc), []byte("arg1")}},
+		},
+	}
+	cisBytes, err := proto.Marshal(cis)
+	if err != nil {
+		t.Fatalf("marshal chaincode invocation spec failed: %v", err)
+	}
+	payloadBytes, err := proto.Marshal(&pb.ChaincodeProposalPayload{Input: cisBytes})
+	if err != nil {
+		t.Fatalf("marshal chaincode proposal payload failed: %v", err)
+	}
+
+	proposal := &fab.TransactionProposal{Proposal: &pb.Proposal{Payload: payloadBytes}}
+
+	fcn, err := ChaincodeInvocationFunction(proposal)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fcn != checkCommitReadinessFunc {
+		t.Fatalf("expected '%s', got '%s'", checkCommitReadinessFunc, fcn)
+	}
+}
+
+func TestChaincodeInvocationFunctionNilProposal(t *testing.T) {
+	if _, err := ChaincodeInvocationFunction(nil); err == nil {
+		t.Fatal("expected an error for a nil proposal")
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+)
+
+// SelectionOutcome is reported back to an OrdererSelector after an attempt to
+// use one of the orderers from its Order, so that selectors which adapt to
+// observed behavior (latency-weighted, circuit-breaker) can update their
+// state.
+type SelectionOutcome struct {
+	// Err is the error returned by the broadcast attempt, or nil on success.
+	Err error
+	// Latency is how long the attempt took to complete (or to fail).
+	Latency time.Duration
+}
+
+// Success reports whether the attempt succeeded.
+func (o SelectionOutcome) Success() bool {
+	return o.Err == nil
+}
+
+// OrdererSelector decides the order in which a single broadcast tries the
+// configured orderers, and is told the outcome of each attempt so it can
+// adapt future choices. Implementations must be safe for concurrent use:
+// Order is called once per broadcast and must return a snapshot that is
+// private to that call, since broadcasts for unrelated transactions run
+// concurrently against the same shared selector instance (e.g. a
+// long-lived MockTransactor.BroadcastOpts.Selector).
+type OrdererSelector interface {
+	// Order returns the full attempt order for one broadcast, computed
+	// fresh on every call. The caller tries orderers in the returned order
+	// until one succeeds or all are exhausted.
+	Order(orderers []fab.Orderer) []fab.Orderer
+	// Report records the outcome of having used orderer, returned by a
+	// prior call to Order.
+	Report(orderer fab.Orderer, outcome SelectionOutcome)
+}
+
+// NewRandomSelector returns the default OrdererSelector: a random permutation
+// of the orderer set, identical to the original hard-coded rand.Perm behavior.
+func NewRandomSelector() OrdererSelector {
+	return &randomSelector{}
+}
+
+type randomSelector struct{}
+
+func (s *randomSelector) Order(orderers []fab.Orderer) []fab.Orderer {
+	perm := rand.Perm(len(orderers))
+	ordered := make([]fab.Orderer, len(orderers))
+	for i, p := range perm {
+		ordered[i] = orderers[p]
+	}
+	return ordered
+}
+
+func (s *randomSelector) Report(orderer fab.Orderer, outcome SelectionOutcome) {
+	// No adaptive state.
+}
+
+// NewRoundRobinSelector returns an OrdererSelector that cycles through the
+// orderer set in the order it is given, advancing the start position by one
+// on every call so that consecutive broadcasts spread load evenly.
+func NewRoundRobinSelector() OrdererSelector {
+	return &roundRobinSelector{}
+}
+
+type roundRobinSelector struct {
+	mu   sync.Mutex
+	next int
+}
+
+func (s *roundRobinSelector) Order(orderers []fab.Orderer) []fab.Orderer {
+	if len(orderers) == 0 {
+		return nil
+	}
+
+	// The starting offset is the only state shared across broadcasts, and
+	// it is read and advanced atomically under the lock, once per call, so
+	// concurrent broadcasts each get a distinct, self-consistent start
+	// position instead of tearing a cursor that was being advanced
+	// attempt-by-attempt.
+	s.mu.Lock()
+	start := s.next
+	s.next = (s.next + 1) % len(orderers)
+	s.mu.Unlock()
+
+	ordered := make([]fab.Orderer, len(orderers))
+	for i := range orderers {
+		ordered[i] = orderers[(start+i)%len(orderers)]
+	}
+	return ordered
+}
+
+func (s *roundRobinSelector) Report(orderer fab.Orderer, outcome SelectionOutcome) {
+	// No adaptive state.
+}
+
+// latencyEWMAAlpha weighs the most recent RTT sample against the running
+// average; lower values smooth out transient blips more aggressively.
+const latencyEWMAAlpha = 0.3
+
+// NewLatencyWeightedSelector returns an OrdererSelector that orders orderers
+// by an exponentially-weighted moving average of their observed
+// SendBroadcast round-trip time, trying the fastest-known orderer first.
+// Failed outcomes are not recorded, so an orderer whose attempts have only
+// failed stays unsampled. Orderers with no samples are treated as equally
+// fast and tried before any orderer with a latency sample.
+func NewLatencyWeightedSelector() OrdererSelector {
+	return &latencyWeightedSelector{ewma: make(map[string]time.Duration)}
+}
+
+type latencyWeightedSelector struct {
+	mu   sync.Mutex
+	ewma map[string]time.Duration
+}
+
+func (s *latencyWeightedSelector) Order(orderers []fab.Orderer) []fab.Orderer {
+	ordered := make([]fab.Orderer, len(orderers))
+	copy(ordered, orderers)
+
+	s.mu.Lock()
+	ewma := make(map[string]time.Duration, len(s.ewma))
+	for k, v := range s.ewma {
+		ewma[k] = v
+	}
+	s.mu.Unlock()
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		li, oki := ewma[ordered[i].URL()]
+		lj, okj := ewma[ordered[j].URL()]
+		if oki != okj {
+			// Unseen orderers sort ahead of ones we already have a sample for.
+			return !oki
+		}
+		return li < lj
+	})
+	return ordered
+}
+
+func (s *latencyWeightedSelector) Report(orderer fab.Orderer, outcome SelectionOutcome) {
+	if !outcome.Success() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, ok := s.ewma[orderer.URL()]
+	if !ok {
+		s.ewma[orderer.URL()] = outcome.Latency
+		return
+	}
+	s.ewma[orderer.URL()] = time.Duration(latencyEWMAAlpha*float64(outcome.Latency) + (1-latencyEWMAAlpha)*float64(prev))
+}
+
+// circuitBreakerCooldown is how long an orderer that returned an error is
+// excluded from selection before being retried.
+const circuitBreakerCooldown = 10 * time.Second
+
+// NewCircuitBreakerSelector returns an OrdererSelector that excludes, for
+// cooldown, any orderer whose most recent reported outcome was an error.
+// This avoids repeatedly picking a dead orderer within a burst of
+// transactions. If every orderer is currently tripped, it falls back to
+// offering them anyway so callers still make progress.
+func NewCircuitBreakerSelector(cooldown time.Duration) OrdererSelector {
+	if cooldown <= 0 {
+		cooldown = circuitBreakerCooldown
+	}
+	return &circuitBreakerSelector{cooldown: cooldown, trippedUntil: make(map[string]time.Time)}
+}
+
+type circuitBreakerSelector struct {
+	mu           sync.Mutex
+	cooldown     time.Duration
+	trippedUntil map[string]time.Time
+}
+
+func (s *circuitBreakerSelector) Order(orderers []fab.Orderer) []fab.Orderer {
+	s.mu.Lock()
+	now := time.Now()
+	available := make([]fab.Orderer, 0, len(orderers))
+	for _, o := range orderers {
+		if until, tripped := s.trippedUntil[o.URL()]; !tripped || now.After(until) {
+			available = append(available, o)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(available) == 0 {
+		available = append(available, orderers...)
+	}
+	return available
+}
+
+func (s *circuitBreakerSelector) Report(orderer fab.Orderer, outcome SelectionOutcome) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if outcome.Success() {
+		delete(s.trippedUntil, orderer.URL())
+		return
+	}
+	s.trippedUntil[orderer.URL()] = time.Now().Add(s.cooldown)
+}
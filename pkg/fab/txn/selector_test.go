@@ -0,0 +1,170 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+)
+
+// assertIsPermutation fails t unless got contains exactly the same orderers
+// as want, regardless of order.
+func assertIsPermutation(t *testing.T, want, got []fab.Orderer) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Fatalf("expected %d orderers, got %d", len(want), len(got))
+	}
+	seen := make(map[string]bool, len(got))
+	for _, o := range got {
+		if seen[o.URL()] {
+			t.Fatalf("orderer '%s' appeared more than once in %v", o.URL(), got)
+		}
+		seen[o.URL()] = true
+	}
+	for _, o := range want {
+		if !seen[o.URL()] {
+			t.Fatalf("orderer '%s' missing from result %v", o.URL(), got)
+		}
+	}
+}
+
+func TestRandomSelectorOrderIsPermutation(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2", "o3")
+	selector := NewRandomSelector()
+
+	for i := 0; i < 10; i++ {
+		assertIsPermutation(t, orderers, selector.Order(orderers))
+	}
+}
+
+func TestRoundRobinSelectorRotatesSequentially(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2", "o3")
+	selector := NewRoundRobinSelector()
+
+	first := selector.Order(orderers)
+	assertIsPermutation(t, orderers, first)
+	second := selector.Order(orderers)
+	assertIsPermutation(t, orderers, second)
+
+	if first[0].URL() == second[0].URL() {
+		t.Fatalf("expected successive calls to start at a different orderer, both started at '%s'", first[0].URL())
+	}
+	if second[0].URL() != first[1].URL() {
+		t.Fatalf("expected the second call to start where the first left off: want '%s', got '%s'", first[1].URL(), second[0].URL())
+	}
+}
+
+// TestRoundRobinSelectorOrderIsRaceFree guards against the bug the chunk0-2
+// fix addressed: concurrent broadcasts sharing one selector instance must
+// each get back a self-consistent permutation, never a torn or truncated
+// slice from another call's in-flight cursor update.
+func TestRoundRobinSelectorOrderIsRaceFree(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2", "o3", "o4", "o5")
+	selector := NewRoundRobinSelector()
+
+	const concurrency = 50
+	results := make([][]fab.Orderer, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = selector.Order(orderers)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		assertIsPermutation(t, orderers, result)
+	}
+}
+
+func TestLatencyWeightedSelectorOrdersByReportedLatency(t *testing.T) {
+	orderers := newFakeOrderers("slow", "fast", "unseen")
+	selector := NewLatencyWeightedSelector()
+
+	selector.Report(orderers[0], SelectionOutcome{Latency: 100 * time.Millisecond})
+	selector.Report(orderers[1], SelectionOutcome{Latency: 10 * time.Millisecond})
+
+	ordered := selector.Order(orderers)
+	assertIsPermutation(t, orderers, ordered)
+
+	urls := make([]string, len(ordered))
+	for i, o := range ordered {
+		urls[i] = o.URL()
+	}
+	// Unseen orderers sort ahead of ones with a known sample, and among
+	// known samples, lower latency sorts first.
+	want := []string{"unseen", "fast", "slow"}
+	for i := range want {
+		if urls[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, urls)
+		}
+	}
+}
+
+func TestLatencyWeightedSelectorIgnoresFailedOutcomes(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2")
+	selector := NewLatencyWeightedSelector()
+
+	selector.Report(orderers[0], SelectionOutcome{Err: errTest, Latency: 100 * time.Millisecond})
+	selector.Report(orderers[1], SelectionOutcome{Latency: 10 * time.Millisecond})
+	// A failed outcome must not seed a latency sample: o1 stays unseen and
+	// sorts ahead of o2, instead of behind it with a 100ms sample.
+	ordered := selector.Order(orderers)
+	assertIsPermutation(t, orderers, ordered)
+	if ordered[0].URL() != "o1" {
+		t.Fatalf("expected the unsampled 'o1' first, got '%s'", ordered[0].URL())
+	}
+}
+
+func TestCircuitBreakerSelectorExcludesTrippedOrderers(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2")
+	selector := NewCircuitBreakerSelector(time.Minute)
+
+	selector.Report(orderers[0], SelectionOutcome{Err: errTest})
+
+	ordered := selector.Order(orderers)
+	if len(ordered) != 1 || ordered[0].URL() != "o2" {
+		t.Fatalf("expected only 'o2' to be offered, got %v", ordered)
+	}
+}
+
+func TestCircuitBreakerSelectorFallsBackWhenAllTripped(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2")
+	selector := NewCircuitBreakerSelector(time.Minute)
+
+	for _, o := range orderers {
+		selector.Report(o, SelectionOutcome{Err: errTest})
+	}
+
+	ordered := selector.Order(orderers)
+	assertIsPermutation(t, orderers, ordered)
+}
+
+func TestCircuitBreakerSelectorRecoversOnSuccess(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2")
+	selector := NewCircuitBreakerSelector(time.Minute)
+
+	selector.Report(orderers[0], SelectionOutcome{Err: errTest})
+	selector.Report(orderers[0], SelectionOutcome{})
+
+	ordered := selector.Order(orderers)
+	assertIsPermutation(t, orderers, ordered)
+}
+
+func TestSelectionOutcomeSuccess(t *testing.T) {
+	if !(SelectionOutcome{}).Success() {
+		t.Fatal("expected a nil-error outcome to be a success")
+	}
+	if (SelectionOutcome{Err: errTest}).Success() {
+		t.Fatal("expected an outcome carrying an error to not be a success")
+	}
+}
@@ -8,9 +8,9 @@ SPDX-License-Identifier: Apache-2.0
 package txn
 
 import (
-	"bytes"
 	reqContext "context"
-	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -26,6 +26,39 @@ import (
 
 var logger = logging.NewLogger("fabsdk/fab")
 
+// BroadcastPolicy determines how a signed envelope is fanned out to the
+// configured orderers.
+type BroadcastPolicy int
+
+const (
+	// SequentialRandom tries orderers one at a time, in random order, and
+	// returns on the first success. This is the original, default behavior.
+	SequentialRandom BroadcastPolicy = iota
+	// ParallelFirstSuccess sends to all orderers concurrently and returns as
+	// soon as any single orderer acknowledges, cancelling the rest.
+	ParallelFirstSuccess
+	// ParallelQuorum sends to all orderers concurrently and returns as soon
+	// as BroadcastOpts.Quorum orderers have acknowledged, cancelling the
+	// remaining in-flight requests.
+	ParallelQuorum
+)
+
+// BroadcastOpts controls how broadcastEnvelope fans a signed envelope out to
+// the orderer set. The zero value reproduces the original sequential-random
+// behavior.
+type BroadcastOpts struct {
+	// Policy selects the fan-out strategy. Defaults to SequentialRandom.
+	Policy BroadcastPolicy
+	// Quorum is the number of acks required before ParallelQuorum returns
+	// success. Ignored by the other policies.
+	Quorum int
+	// Selector picks the order in which orderers are tried under
+	// SequentialRandom. If nil, a fresh random permutation is used, matching
+	// the original behavior. Ignored by the parallel policies, which address
+	// every orderer at once.
+	Selector OrdererSelector
+}
+
 // CCProposalType reflects transitions in the chaincode lifecycle
 type CCProposalType int
 
@@ -33,6 +66,18 @@ type CCProposalType int
 const (
 	Instantiate CCProposalType = iota
 	Upgrade
+	// ApproveForMyOrg records this org's approval of a chaincode definition,
+	// per the Fabric 2.x (_lifecycle) chaincode lifecycle.
+	ApproveForMyOrg
+	// CommitChaincodeDefinition commits a chaincode definition to the
+	// channel once enough orgs have approved it.
+	CommitChaincodeDefinition
+	// CheckCommitReadiness queries which orgs have approved a chaincode
+	// definition so far, without committing it.
+	CheckCommitReadiness
+	// QueryChaincodeDefinition queries a chaincode definition that has
+	// already been committed to the channel.
+	QueryChaincodeDefinition
 )
 
 // New create a transaction with proposal response, following the endorsement policy.
@@ -41,8 +86,33 @@ func New(request fab.TransactionRequest) (*fab.Transaction, error) {
 		return nil, errors.New("at least one proposal response is necessary")
 	}
 
-	proposal := request.Proposal
+	// Feed the already-collected responses through the same streaming
+	// collector CollectEndorsements uses, so New and a caller doing its own
+	// streaming collection end up with identical validation and dedup
+	// behavior. The required count is the number of distinct endorsers
+	// among request.ProposalResponses, not the raw response count: two
+	// responses from the same endorser dedup down to one endorsement, and
+	// requiring len(request.ProposalResponses) of them would then never be
+	// satisfied.
+	responses := make(chan *fab.TransactionProposalResponse, len(request.ProposalResponses))
+	seenEndorsers := make(map[string]bool, len(request.ProposalResponses))
+	for _, r := range request.ProposalResponses {
+		responses <- r
+		seenEndorsers[string(r.ProposalResponse.Endorsement.Endorser)] = true
+	}
+	close(responses)
 
+	set, err := CollectEndorsements(reqContext.Background(), request.Proposal, responses, MinEndorsements(len(seenEndorsers)))
+	if err != nil {
+		return nil, err
+	}
+
+	return set.Transaction()
+}
+
+// buildTransaction assembles the final *fab.Transaction from a proposal, its
+// canonical response payload, and the endorsements gathered for it.
+func buildTransaction(proposal *fab.TransactionProposal, responsePayload []byte, endorsements []*pb.Endorsement) (*fab.Transaction, error) {
 	// the original header
 	hdr, err := protos_utils.GetHeader(proposal.Header)
 	if err != nil {
@@ -61,22 +131,6 @@ func New(request fab.TransactionRequest) (*fab.Transaction, error) {
 		return nil, err
 	}
 
-	responsePayload := request.ProposalResponses[0].ProposalResponse.Payload
-	for _, r := range request.ProposalResponses {
-		if r.ProposalResponse.Response.Status != 200 {
-			return nil, errors.Errorf("proposal response was not successful, error code %d, msg %s", r.ProposalResponse.Response.Status, r.ProposalResponse.Response.Message)
-		}
-		if !bytes.Equal(responsePayload, r.ProposalResponse.Payload) {
-			return nil, errors.Errorf("proposal response payloads are not the same (%v, %v)", responsePayload, r.ProposalResponse.Payload)
-		}
-	}
-
-	// fill endorsements
-	endorsements := make([]*pb.Endorsement, len(request.ProposalResponses))
-	for n, r := range request.ProposalResponses {
-		endorsements[n] = r.ProposalResponse.Endorsement
-	}
-
 	// create ChaincodeEndorsedAction
 	cea := &pb.ChaincodeEndorsedAction{ProposalResponsePayload: responsePayload, Endorsements: endorsements}
 
@@ -106,6 +160,12 @@ func New(request fab.TransactionRequest) (*fab.Transaction, error) {
 
 // Send send a transaction to the chain’s orderer service (one or more orderer endpoints) for consensus and committing to the ledger.
 func Send(ctx contextApi.Client, tx *fab.Transaction, orderers []fab.Orderer) (*fab.TransactionResponse, error) {
+	return SendWithOpts(ctx, tx, orderers, BroadcastOpts{Policy: SequentialRandom})
+}
+
+// SendWithOpts is Send with control over the orderer fan-out strategy. See
+// BroadcastOpts for the available policies.
+func SendWithOpts(ctx contextApi.Client, tx *fab.Transaction, orderers []fab.Orderer, opts BroadcastOpts) (*fab.TransactionResponse, error) {
 	if orderers == nil || len(orderers) == 0 {
 		return nil, errors.New("orderers is nil")
 	}
@@ -130,7 +190,7 @@ func Send(ctx contextApi.Client, tx *fab.Transaction, orderers []fab.Orderer) (*
 	// create the payload
 	payload := common.Payload{Header: hdr, Data: txBytes}
 
-	transactionResponse, err := BroadcastPayload(ctx, &payload, orderers)
+	transactionResponse, err := BroadcastPayloadWithOpts(ctx, &payload, orderers, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -141,6 +201,12 @@ func Send(ctx contextApi.Client, tx *fab.Transaction, orderers []fab.Orderer) (*
 // BroadcastPayload will send the given payload to some orderer, picking random endpoints
 // until all are exhausted
 func BroadcastPayload(ctx contextApi.Client, payload *common.Payload, orderers []fab.Orderer) (*fab.TransactionResponse, error) {
+	return BroadcastPayloadWithOpts(ctx, payload, orderers, BroadcastOpts{Policy: SequentialRandom})
+}
+
+// BroadcastPayloadWithOpts is BroadcastPayload with control over the orderer
+// fan-out strategy. See BroadcastOpts for the available policies.
+func BroadcastPayloadWithOpts(ctx contextApi.Client, payload *common.Payload, orderers []fab.Orderer, opts BroadcastOpts) (*fab.TransactionResponse, error) {
 	// Check if orderers are defined
 	if len(orderers) == 0 {
 		return nil, errors.New("orderers not set")
@@ -151,27 +217,49 @@ func BroadcastPayload(ctx contextApi.Client, payload *common.Payload, orderers [
 		return nil, err
 	}
 
-	return broadcastEnvelope(ctx, envelope, orderers)
+	return broadcastEnvelope(ctx, envelope, orderers, opts)
 }
 
-// broadcastEnvelope will send the given envelope to some orderer, picking random endpoints
-// until all are exhausted
-func broadcastEnvelope(ctx contextApi.Client, envelope *fab.SignedEnvelope, orderers []fab.Orderer) (*fab.TransactionResponse, error) {
+// broadcastEnvelope will send the given envelope to the orderers according to
+// opts.Policy.
+func broadcastEnvelope(ctx contextApi.Client, envelope *fab.SignedEnvelope, orderers []fab.Orderer, opts BroadcastOpts) (*fab.TransactionResponse, error) {
 	// Check if orderers are defined
 	if len(orderers) == 0 {
 		return nil, errors.New("orderers not set")
 	}
 
-	// Copy aside the ordering service endpoints
-	randOrderers := []fab.Orderer{}
-	for _, o := range orderers {
-		randOrderers = append(randOrderers, o)
+	switch opts.Policy {
+	case ParallelFirstSuccess:
+		return broadcastEnvelopeParallel(ctx, envelope, orderers, 1)
+	case ParallelQuorum:
+		if opts.Quorum <= 0 || opts.Quorum > len(orderers) {
+			return nil, errors.Errorf("quorum %d is invalid for %d orderers", opts.Quorum, len(orderers))
+		}
+		return broadcastEnvelopeParallel(ctx, envelope, orderers, opts.Quorum)
+	default:
+		return broadcastEnvelopeSequentialRandom(ctx, envelope, orderers, opts.Selector)
 	}
+}
 
-	// Iterate them in a random order and try broadcasting 1 by 1
+// broadcastEnvelopeSequentialRandom will send the given envelope to orderers one at
+// a time, in the order chosen by selector, until one succeeds or all are exhausted.
+// If selector is nil, a fresh random permutation is used, matching the original
+// hard-coded behavior.
+func broadcastEnvelopeSequentialRandom(ctx contextApi.Client, envelope *fab.SignedEnvelope, orderers []fab.Orderer, selector OrdererSelector) (*fab.TransactionResponse, error) {
+	if selector == nil {
+		selector = NewRandomSelector()
+	}
+
+	// Order is computed once per broadcast, so concurrent broadcasts sharing
+	// the same selector instance never interleave on a shared cursor.
+	ordered := selector.Order(orderers)
+
+	// Iterate them in selector order and try broadcasting 1 by 1
 	var errResp error
-	for _, i := range rand.Perm(len(randOrderers)) {
-		resp, err := sendBroadcast(ctx, envelope, randOrderers[i])
+	for _, orderer := range ordered {
+		start := time.Now()
+		resp, err := sendBroadcast(ctx, envelope, orderer)
+		selector.Report(orderer, SelectionOutcome{Err: err, Latency: time.Since(start)})
 		if err != nil {
 			errResp = err
 		} else {
@@ -181,10 +269,87 @@ func broadcastEnvelope(ctx contextApi.Client, envelope *fab.SignedEnvelope, orde
 	return nil, errResp
 }
 
+// broadcastEnvelopeParallel fans the envelope out to every orderer concurrently
+// and returns as soon as quorum acks have been received, cancelling the
+// remaining in-flight requests. If quorum is never reached, it returns the
+// last error observed.
+func broadcastEnvelopeParallel(ctx contextApi.Client, envelope *fab.SignedEnvelope, orderers []fab.Orderer, quorum int) (*fab.TransactionResponse, error) {
+	reqCtx, cancel := reqContext.WithCancel(context.NewRequest(ctx))
+	defer cancel()
+
+	return fanOutBroadcast(reqCtx, orderers, quorum, func(attemptCtx reqContext.Context, orderer fab.Orderer) (*fab.TransactionResponse, error) {
+		return sendBroadcastCtx(attemptCtx, ctx, envelope, orderer)
+	})
+}
+
+// fanOutBroadcast sends to every orderer concurrently via send and returns as
+// soon as quorum successful responses have been received, cancelling reqCtx
+// (aborting the remaining in-flight sends) as soon as that happens or all
+// sends have completed. If quorum is never reached, it returns the last
+// error observed. Split out from broadcastEnvelopeParallel so the fan-out,
+// quorum and cancellation behavior can be exercised directly against a stub
+// send, independent of contextApi.Client.
+func fanOutBroadcast(reqCtx reqContext.Context, orderers []fab.Orderer, quorum int, send func(reqContext.Context, fab.Orderer) (*fab.TransactionResponse, error)) (*fab.TransactionResponse, error) {
+	type result struct {
+		resp *fab.TransactionResponse
+		err  error
+	}
+
+	reqCtx, cancel := reqContext.WithCancel(reqCtx)
+	defer cancel()
+
+	results := make(chan result, len(orderers))
+	var wg sync.WaitGroup
+	for _, o := range orderers {
+		wg.Add(1)
+		go func(orderer fab.Orderer) {
+			defer wg.Done()
+			resp, err := send(reqCtx, orderer)
+			select {
+			case results <- result{resp: resp, err: err}:
+			case <-reqCtx.Done():
+			}
+		}(o)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var acks int
+	var lastResp *fab.TransactionResponse
+	var lastErr error
+	for r := range results {
+		if r.err != nil {
+			lastErr = r.err
+			continue
+		}
+		acks++
+		lastResp = r.resp
+		if acks >= quorum {
+			cancel()
+			return lastResp, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.Errorf("quorum of %d not reached (got %d acks)", quorum, acks)
+	}
+	return nil, lastErr
+}
+
 func sendBroadcast(ctx contextApi.Client, envelope *fab.SignedEnvelope, orderer fab.Orderer) (*fab.TransactionResponse, error) {
-	logger.Debugf("Broadcasting envelope to orderer :%s\n", orderer.URL())
 	reqCtx, cancel := reqContext.WithTimeout(context.NewRequest(ctx), ctx.Config().TimeoutOrDefault(core.OrdererResponse))
 	defer cancel()
+	return sendBroadcastCtx(reqCtx, ctx, envelope, orderer)
+}
+
+// sendBroadcastCtx is sendBroadcast with an explicit, cancellable request
+// context so that a fan-out caller can abort outstanding requests once
+// enough orderers have acked.
+func sendBroadcastCtx(reqCtx reqContext.Context, ctx contextApi.Client, envelope *fab.SignedEnvelope, orderer fab.Orderer) (*fab.TransactionResponse, error) {
+	logger.Debugf("Broadcasting envelope to orderer :%s\n", orderer.URL())
+	reqCtx, cancel := reqContext.WithTimeout(reqCtx, ctx.Config().TimeoutOrDefault(core.OrdererResponse))
+	defer cancel()
 	if _, err := orderer.SendBroadcast(reqCtx, envelope); err != nil {
 		logger.Debugf("Receive Error Response from orderer :%v\n", err)
 		return nil, errors.Wrapf(err, "calling orderer '%s' failed", orderer.URL())
@@ -194,8 +359,19 @@ func sendBroadcast(ctx contextApi.Client, envelope *fab.SignedEnvelope, orderer
 	return &fab.TransactionResponse{Orderer: orderer.URL()}, nil
 }
 
-// SendPayload sends the given payload to each orderer and returns a block response
+// SendPayload sends the given payload to some orderer, picking random
+// endpoints until all are exhausted. This is SendPayloadWithSelector with
+// NewRandomSelector; see SendPayloadWithSelector to plug in a different
+// OrdererSelector.
 func SendPayload(ctx contextApi.Client, payload *common.Payload, orderers []fab.Orderer) (*common.Block, error) {
+	return SendPayloadWithSelector(ctx, payload, orderers, nil)
+}
+
+// SendPayloadWithSelector is SendPayload with control over the order
+// orderers are tried in, via the same OrdererSelector
+// broadcastEnvelopeSequentialRandom uses. If selector is nil, a fresh random
+// permutation is used, matching the original hard-coded behavior.
+func SendPayloadWithSelector(ctx contextApi.Client, payload *common.Payload, orderers []fab.Orderer, selector OrdererSelector) (*common.Block, error) {
 	if orderers == nil || len(orderers) == 0 {
 		return nil, errors.New("orderers not set")
 	}
@@ -205,16 +381,17 @@ func SendPayload(ctx contextApi.Client, payload *common.Payload, orderers []fab.
 		return nil, err
 	}
 
-	// Copy aside the ordering service endpoints
-	randOrderers := []fab.Orderer{}
-	for _, o := range orderers {
-		randOrderers = append(randOrderers, o)
+	if selector == nil {
+		selector = NewRandomSelector()
 	}
+	ordered := selector.Order(orderers)
 
-	// Iterate them in a random order and try broadcasting 1 by 1
+	// Iterate them in selector order and try broadcasting 1 by 1
 	var errResp error
-	for _, i := range rand.Perm(len(randOrderers)) {
-		resp, err := sendEnvelope(ctx, envelope, randOrderers[i])
+	for _, orderer := range ordered {
+		start := time.Now()
+		resp, err := sendEnvelope(ctx, envelope, orderer)
+		selector.Report(orderer, SelectionOutcome{Err: err, Latency: time.Since(start)})
 		if err != nil {
 			errResp = err
 		} else {
@@ -0,0 +1,91 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package txn
+
+import (
+	reqContext "context"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/context/api/fab"
+)
+
+func TestFanOutBroadcastQuorumReachedDespiteErrors(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2", "o3")
+	send := func(ctx reqContext.Context, orderer fab.Orderer) (*fab.TransactionResponse, error) {
+		if orderer.URL() == "o1" {
+			return nil, errTest
+		}
+		return &fab.TransactionResponse{Orderer: orderer.URL()}, nil
+	}
+
+	resp, err := fanOutBroadcast(reqContext.Background(), orderers, 2, send)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp == nil {
+		t.Fatal("expected a response once quorum was reached")
+	}
+}
+
+func TestFanOutBroadcastQuorumNeverReached(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2", "o3")
+	send := func(ctx reqContext.Context, orderer fab.Orderer) (*fab.TransactionResponse, error) {
+		return nil, errTest
+	}
+
+	_, err := fanOutBroadcast(reqContext.Background(), orderers, 2, send)
+	if err == nil {
+		t.Fatal("expected an error when quorum can never be reached")
+	}
+}
+
+// TestFanOutBroadcastFirstSuccessRaces exercises the ParallelFirstSuccess
+// policy (quorum of 1): the fast orderer's response must win and the call
+// must return well before the slow orderer would have answered, proving the
+// slow attempt was not awaited.
+func TestFanOutBroadcastFirstSuccessRaces(t *testing.T) {
+	const slowDelay = 200 * time.Millisecond
+	orderers := newFakeOrderers("fast", "slow")
+	send := func(ctx reqContext.Context, orderer fab.Orderer) (*fab.TransactionResponse, error) {
+		if orderer.URL() == "slow" {
+			select {
+			case <-time.After(slowDelay):
+			case <-ctx.Done():
+			}
+			return &fab.TransactionResponse{Orderer: orderer.URL()}, nil
+		}
+		return &fab.TransactionResponse{Orderer: orderer.URL()}, nil
+	}
+
+	start := time.Now()
+	resp, err := fanOutBroadcast(reqContext.Background(), orderers, 1, send)
+	elapsed := time.Since(start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Orderer != "fast" {
+		t.Fatalf("expected the fast orderer to win, got '%s'", resp.Orderer)
+	}
+	if elapsed >= slowDelay {
+		t.Fatalf("expected to return well before the slow orderer's %s delay, took %s", slowDelay, elapsed)
+	}
+}
+
+// TestBroadcastEnvelopeRejectsInvalidQuorum exercises broadcastEnvelope's
+// validation directly: an invalid quorum must be rejected before any orderer
+// is contacted, so ctx is never touched on this path.
+func TestBroadcastEnvelopeRejectsInvalidQuorum(t *testing.T) {
+	orderers := newFakeOrderers("o1", "o2")
+
+	if _, err := broadcastEnvelope(nil, nil, orderers, BroadcastOpts{Policy: ParallelQuorum, Quorum: 0}); err == nil {
+		t.Fatal("expected an error for a zero quorum")
+	}
+	if _, err := broadcastEnvelope(nil, nil, orderers, BroadcastOpts{Policy: ParallelQuorum, Quorum: len(orderers) + 1}); err == nil {
+		t.Fatal("expected an error for a quorum larger than the orderer set")
+	}
+}